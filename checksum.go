@@ -0,0 +1,134 @@
+package s3gof3r
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Checksum algorithm identifiers for Config.ChecksumAlgorithm. Setting one
+// switches part uploads from a pre-computed Content-MD5 — which requires
+// buffering the whole part through an MD5 hash before the PUT can start —
+// to a trailing checksum computed while the part streams out, per
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-trailing-checksums.html.
+//
+// Only takes effect when SignatureVersion is SignatureV4 (the default);
+// it is ignored under SignatureV2, which has no aws-chunked trailer
+// support in this package.
+const (
+	ChecksumCRC32C = "CRC32C"
+	ChecksumSHA256 = "SHA256"
+)
+
+// checksumTrailerHeader is the x-amz-checksum-* trailer name S3 expects
+// for algorithm, both as the x-amz-trailer value on the request and as
+// the header name S3 echoes the computed checksum under in the response.
+func checksumTrailerHeader(algorithm string) string {
+	switch algorithm {
+	case ChecksumCRC32C:
+		return "x-amz-checksum-crc32c"
+	case ChecksumSHA256:
+		return "x-amz-checksum-sha256"
+	default:
+		return ""
+	}
+}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA256:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// trailerBodyEncodedLength returns the Content-Length of a payload of
+// size n once framed per trailerBody: a single chunk holding all of the
+// data (omitted when n is 0, since the last-chunk below already says
+// "no more data"), the terminating zero-length chunk, and the trailer
+// header line.
+func trailerBodyEncodedLength(n int64, algorithm string) int64 {
+	sumLen := base64.StdEncoding.EncodedLen(crc32.Size)
+	if algorithm == ChecksumSHA256 {
+		sumLen = base64.StdEncoding.EncodedLen(sha256.Size)
+	}
+	trailer := int64(len(checksumTrailerHeader(algorithm))) + 1 /* ':' */ + int64(sumLen) + 2 /* \r\n */ + 2 /* \r\n */
+	lastChunk := int64(len("0\r\n"))
+	if n == 0 {
+		return lastChunk + trailer
+	}
+	chunkHeader := int64(len(fmt.Sprintf("%x\r\n", n)))
+	return chunkHeader + n + 2 /* \r\n after data */ + lastChunk + trailer
+}
+
+// trailerBody wraps r in the minimal aws-chunked framing required to
+// send a trailing checksum: the payload as one chunk of size n, the
+// terminating zero-length chunk, and the x-amz-checksum-* trailer
+// itself, computed incrementally as r is read so the part never needs
+// to be buffered through a hash before the PUT starts. Framing it as a
+// single chunk, rather than one chunk per read, is what keeps its
+// length predictable up front: trailerBodyEncodedLength's math assumes
+// exactly this framing.
+type trailerBody struct {
+	r      io.Reader
+	h      hash.Hash
+	header string
+	size   int64 // total chunk size, fixed at construction
+	remain int64 // bytes of chunk data not yet read from r
+
+	frame []byte // buffered, already-framed bytes not yet returned to the caller
+	done  bool
+}
+
+func newTrailerBody(r io.Reader, n int64, algorithm string) *trailerBody {
+	b := &trailerBody{r: r, h: newChecksumHash(algorithm), header: checksumTrailerHeader(algorithm), size: n, remain: n}
+	if n > 0 {
+		b.frame = []byte(fmt.Sprintf("%x\r\n", n))
+	}
+	return b
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	for len(b.frame) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		if b.remain > 0 {
+			buf := make([]byte, min(b.remain, int64(sigV4ChunkSize)))
+			n, err := b.r.Read(buf)
+			if n > 0 {
+				if _, herr := b.h.Write(buf[:n]); herr != nil {
+					return 0, herr
+				}
+				b.frame = append(b.frame, buf[:n]...)
+				b.remain -= int64(n)
+			}
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			if err == io.EOF && b.remain > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			continue
+		}
+		// No more chunk data. For a non-empty chunk, terminate its data
+		// before the last-chunk; for a zero-size part there was no data
+		// chunk to begin with, so the last-chunk below is the only chunk.
+		if b.size > 0 {
+			b.frame = append(b.frame, "\r\n"...)
+		}
+		sum := base64.StdEncoding.EncodeToString(b.h.Sum(nil))
+		b.frame = append(b.frame, "0\r\n"...)
+		b.frame = append(b.frame, []byte(fmt.Sprintf("%s:%s\r\n\r\n", b.header, sum))...)
+		b.done = true
+	}
+	n := copy(p, b.frame)
+	b.frame = b.frame[n:]
+	return n, nil
+}
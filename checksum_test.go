@@ -0,0 +1,68 @@
+package s3gof3r
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestTrailerBodyLengthMatches guards against trailerBody and
+// trailerBodyEncodedLength disagreeing about framing: a mismatch here
+// means every streamed part would be truncated or padded relative to
+// its declared Content-Length.
+func TestTrailerBodyLengthMatches(t *testing.T) {
+	for _, size := range []int64{0, 1, 100, sigV4ChunkSize, sigV4ChunkSize + 1, 5 * 1024 * 1024, 5*1024*1024 + 963} {
+		for _, algo := range []string{ChecksumCRC32C, ChecksumSHA256} {
+			data := bytes.Repeat([]byte("a"), int(size))
+			tb := newTrailerBody(bytes.NewReader(data), size, algo)
+			out, err := io.ReadAll(tb)
+			if err != nil {
+				t.Fatalf("size=%d algo=%s: ReadAll: %v", size, algo, err)
+			}
+			if want := trailerBodyEncodedLength(size, algo); int64(len(out)) != want {
+				t.Fatalf("size=%d algo=%s: encoded length = %d, trailerBodyEncodedLength = %d", size, algo, len(out), want)
+			}
+		}
+	}
+}
+
+// TestTrailerBodyZeroLengthFraming checks a zero-byte part is framed as
+// a single last-chunk, not a data chunk of size 0 followed by a second,
+// redundant last-chunk.
+func TestTrailerBodyZeroLengthFraming(t *testing.T) {
+	out, err := io.ReadAll(newTrailerBody(strings.NewReader(""), 0, ChecksumCRC32C))
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	s := string(out)
+	if !strings.HasPrefix(s, "0\r\n") {
+		t.Fatalf("expected body to start with a single last-chunk, got %q", s)
+	}
+	if strings.Count(s, "0\r\n") != 1 {
+		t.Fatalf("expected exactly one last-chunk marker, got %q", s)
+	}
+}
+
+// TestTrailerBodyByteAtATimeReads exercises Read with one-byte buffers,
+// the way a slow or unbuffered io.Copy destination might, to catch
+// state tracked incorrectly across many short reads.
+func TestTrailerBodyByteAtATimeReads(t *testing.T) {
+	data := bytes.Repeat([]byte("xyz"), 100)
+	tb := newTrailerBody(bytes.NewReader(data), int64(len(data)), ChecksumSHA256)
+	var got []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := tb.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if want := trailerBodyEncodedLength(int64(len(data)), ChecksumSHA256); int64(len(got)) != want {
+		t.Fatalf("byte-at-a-time encoded length = %d, want %d", len(got), want)
+	}
+}
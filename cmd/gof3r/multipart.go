@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// multipartUsage is the docopt usage string for the `gof3r multipart` subcommand.
+var multipartUsage = `
+usage:
+   gof3r multipart --list [--prefix=<prefix>] [--delim=<delim>]
+   gof3r multipart --abort-stale --older-than=<duration> [--prefix=<prefix>] [--dry-run]
+
+   options:
+      -h --help
+      --list                 List in-progress multipart uploads.
+      --abort-stale          Abort uploads older than --older-than.
+      --older-than=<duration>  Minimum age of an upload to abort, e.g. 24h. [default: 24h]
+      --prefix=<prefix>      Only consider keys with this prefix.
+      --delim=<delim>        Delimiter for grouping keys, as in S3's ListMultipartUploads.
+      --dry-run              Print what would be aborted without aborting it.
+`
+
+// multipart lists or aborts orphaned multipart uploads left behind by
+// crashed processes. These uploads are otherwise invisible to normal
+// listing operations but continue to accrue storage charges until
+// completed or aborted.
+func multipart(args map[string]interface{}) {
+	b := getBucket()
+	ctx := context.Background()
+
+	prefix, _ := args["--prefix"].(string)
+	delim, _ := args["--delim"].(string)
+
+	uploads, err := b.ListMultipartUploads(ctx, prefix, delim)
+	if err != nil {
+		fatalErr(err)
+	}
+
+	if args["--list"].(bool) {
+		for _, u := range uploads {
+			fmt.Printf("%s\t%s\t%s\n", u.UploadID, u.Initiated.Format(time.RFC3339), u.Key)
+		}
+		return
+	}
+
+	olderThan, err := time.ParseDuration(args["--older-than"].(string))
+	if err != nil {
+		fatalErr(fmt.Errorf("invalid --older-than duration: %v", err))
+	}
+	cutoff := time.Now().Add(-olderThan)
+	dryRun, _ := args["--dry-run"].(bool)
+
+	for _, u := range uploads {
+		if u.Initiated.After(cutoff) {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would abort %s\t%s\t%s\n", u.UploadID, u.Initiated.Format(time.RFC3339), u.Key)
+			continue
+		}
+		if err := b.AbortMultipart(ctx, u.Key, u.UploadID); err != nil {
+			fatalErr(fmt.Errorf("abort %s (%s): %v", u.Key, u.UploadID, err))
+		}
+		fmt.Printf("aborted %s\t%s\t%s\n", u.UploadID, u.Initiated.Format(time.RFC3339), u.Key)
+	}
+}
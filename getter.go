@@ -0,0 +1,40 @@
+package s3gof3r
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// GetReader opens key for reading, issuing a single GET and streaming the
+// response body directly; it does not split large objects into concurrent
+// ranged requests the way putter fans a part out across many concurrent
+// uploads. h, if non-nil, is merged into the request (e.g. a Range header
+// for a partial read); c.Encryption's SSE-C customer-key headers, if
+// configured, are added automatically. The caller must Close the returned
+// body.
+//
+// S3 requires the same customer-key headers on every GET of an SSE-C
+// object that were used on the PUT that created it; SSE-S3 and SSE-KMS
+// objects need no such headers, since S3 decrypts them transparently.
+func (b *Bucket) GetReader(ctx context.Context, key string, h http.Header, c *Config) (io.ReadCloser, http.Header, error) {
+	u, err := b.url(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqHeader := h.Clone()
+	if reqHeader == nil {
+		reqHeader = make(http.Header)
+	}
+	c.Encryption.setGetHeaders(reqHeader)
+	resp, err := retryRequest(ctx, c, b, "GET", u.String(), nil, reqHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 206 {
+		err = wrapRespErr(resp)
+		checkClose(resp.Body, &err)
+		return nil, nil, err
+	}
+	return resp.Body, resp.Header, nil
+}
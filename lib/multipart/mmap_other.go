@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package multipart
+
+import "fmt"
+
+// mmapAlloc is unsupported on this platform; SharedBufferPool falls back
+// to heap allocation when it returns an error.
+func mmapAlloc(size int) ([]byte, error) {
+	return nil, fmt.Errorf("multipart: mmap buffer pool not supported on this platform")
+}
+
+func mmapFree(b []byte) error {
+	return nil
+}
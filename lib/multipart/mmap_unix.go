@@ -0,0 +1,16 @@
+//go:build linux || darwin
+
+package multipart
+
+import "syscall"
+
+// mmapAlloc returns an anonymous, private mapping of size bytes, used to
+// back part buffers outside the Go heap.
+func mmapAlloc(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+// mmapFree unmaps a slice previously returned by mmapAlloc.
+func mmapFree(b []byte) error {
+	return syscall.Munmap(b)
+}
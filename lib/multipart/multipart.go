@@ -0,0 +1,332 @@
+// Package multipart implements a reusable multipart upload engine: part
+// queueing, a bounded worker pool, configurable jittered retries, and a
+// buffer pool for staging part bodies. It knows nothing about any
+// particular object storage API; callers supply a ChunkWriter that does
+// the actual create/upload/complete/abort calls. s3gof3r's S3 putter is
+// one such ChunkWriter; an in-memory fake for tests, or a GCS/OSS
+// adapter, can be dropped in without touching this package.
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Part describes one uploaded part, as required to complete the upload.
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// ChunkWriter is the storage-specific half of a multipart upload. A
+// MultipartWriter drives one ChunkWriter through CreateUpload, a series
+// of concurrent UploadPart calls, and finally Complete (or Abort on
+// failure).
+type ChunkWriter interface {
+	// CreateUpload initiates the upload.
+	CreateUpload(ctx context.Context) error
+	// UploadPart sends part number n, of the given length, read from r,
+	// along with its base64-encoded MD5 (as a Content-MD5 header would
+	// expect). It returns the part's ETag as reported by the store.
+	UploadPart(ctx context.Context, n int, r io.ReadSeeker, len int64, md5 string) (etag string, err error)
+	// Complete finishes the upload given the full set of uploaded parts.
+	Complete(ctx context.Context, parts []*Part) error
+	// Abort cancels the upload. Implementations should not error on
+	// an upload that no longer exists.
+	Abort(ctx context.Context) error
+}
+
+// Config controls the concurrency, part sizing, and retry behavior of a
+// MultipartWriter. It is intentionally a small subset of s3gof3r.Config
+// so other ChunkWriters aren't forced to depend on S3-specific options.
+type Config struct {
+	Concurrency int
+	NTry        int
+	PartSize    int64
+	MinPartSize int64
+	MaxPartSize int64
+
+	// Retryer controls the delay before, and whether there is, a retry
+	// of a failed UploadPart call. DefaultRetryer() is used if nil.
+	Retryer Retryer
+
+	// SkipMD5 skips computing a whole-part MD5 before calling UploadPart,
+	// leaving its md5 argument empty. Set this when the ChunkWriter
+	// computes its own per-part integrity check instead (e.g. a trailing
+	// checksum sent while the part streams out).
+	SkipMD5 bool
+}
+
+// BufferPool supplies and reclaims the *bytes.Buffer values used to
+// stage part bodies before they are uploaded. Implementations may pool
+// buffers process-wide and across concurrent MultipartWriters.
+type BufferPool interface {
+	Get() *bytes.Buffer
+	Give(*bytes.Buffer)
+	// SetSize adjusts the size of buffers the pool hands out going
+	// forward, without invalidating buffers already on loan.
+	SetSize(int64)
+	// Close releases any resources held by the pool.
+	Close()
+}
+
+type job struct {
+	r    io.ReadSeeker
+	len  int64
+	part *Part
+	buf  *bytes.Buffer
+	md5  string
+}
+
+// MultipartWriter is an io.WriteCloser that buffers writes into parts and
+// uploads them concurrently through a ChunkWriter, in the same fashion
+// as S3's multipart API: parts are independent, may complete out of
+// order, and are stitched back together by Complete.
+type MultipartWriter struct {
+	ctx  context.Context
+	cw   ChunkWriter
+	c    Config
+	pool BufferPool
+
+	retryer Retryer
+
+	bufsz  int64
+	buf    *bytes.Buffer
+	ch     chan *job
+	partN  int
+	closed bool
+	err    error
+	wg     sync.WaitGroup
+
+	parts []*Part
+}
+
+// NewMultipartWriter starts a multipart upload against cw, using pool to
+// stage part buffers. c.Concurrency workers are started immediately to
+// upload parts as they are flushed. ctx bounds the whole upload: canceling
+// it stops retries and fails the next Write or Close.
+func NewMultipartWriter(ctx context.Context, cw ChunkWriter, c Config, pool BufferPool) (*MultipartWriter, error) {
+	mw := newMultipartWriter(ctx, cw, c, pool)
+	if err := cw.CreateUpload(ctx); err != nil {
+		return nil, err
+	}
+	mw.start()
+	return mw, nil
+}
+
+// ResumeMultipartWriter picks up an already-initiated upload, seeding the
+// set of parts already uploaded so that subsequent Writes continue at the
+// next part boundary instead of starting over. Unlike NewMultipartWriter,
+// it does not call cw.CreateUpload.
+func ResumeMultipartWriter(ctx context.Context, cw ChunkWriter, parts []*Part, c Config, pool BufferPool) *MultipartWriter {
+	mw := newMultipartWriter(ctx, cw, c, pool)
+	mw.parts = parts
+	for _, p := range parts {
+		if p.Number > mw.partN {
+			mw.partN = p.Number
+		}
+	}
+	// advance bufsz the same way flush does, so resumed parts keep
+	// growing at the same part-count doubling boundaries as before.
+	for n := (mw.partN / 1000) * 1000; n > 0; n -= 1000 {
+		mw.bufsz = min64(mw.bufsz*2, mw.c.MaxPartSize)
+	}
+	mw.start()
+	return mw
+}
+
+func newMultipartWriter(ctx context.Context, cw ChunkWriter, c Config, pool BufferPool) *MultipartWriter {
+	retryer := c.Retryer
+	if retryer == nil {
+		retryer = DefaultRetryer()
+	}
+	mw := &MultipartWriter{
+		ctx:     ctx,
+		cw:      cw,
+		c:       c,
+		pool:    pool,
+		retryer: retryer,
+		bufsz:   max64(c.MinPartSize, c.PartSize),
+	}
+	mw.c.Concurrency = maxInt(c.Concurrency, 1)
+	mw.c.NTry = maxInt(c.NTry, 1)
+	return mw
+}
+
+func (mw *MultipartWriter) start() {
+	mw.pool.SetSize(mw.bufsz)
+	mw.ch = make(chan *job)
+	for i := 0; i < mw.c.Concurrency; i++ {
+		go mw.worker()
+	}
+}
+
+func (mw *MultipartWriter) Write(b []byte) (int, error) {
+	if mw.closed {
+		mw.Abort()
+		return 0, fmt.Errorf("multipart: write on closed MultipartWriter")
+	}
+	if mw.err != nil {
+		mw.Abort()
+		return 0, mw.err
+	}
+	if err := mw.ctx.Err(); err != nil {
+		mw.err = err
+		mw.Abort()
+		return 0, err
+	}
+	if mw.buf == nil {
+		mw.buf = mw.pool.Get()
+		mw.buf.Grow(int(mw.bufsz))
+	}
+	n, err := mw.buf.Write(b)
+	if err != nil {
+		mw.Abort()
+		return n, err
+	}
+	if int64(mw.buf.Len()) >= mw.bufsz {
+		mw.flush()
+	}
+	return n, nil
+}
+
+func (mw *MultipartWriter) flush() {
+	mw.wg.Add(1)
+	mw.partN++
+	buf := mw.buf
+	mw.buf = nil
+
+	// Config.SkipMD5 lets a ChunkWriter that computes its own per-part
+	// integrity check while streaming (e.g. a trailing checksum) skip
+	// this pre-upload hash, so the part's buffer never has to be read
+	// through twice before the PUT can start.
+	var md5Sum string
+	if !mw.c.SkipMD5 {
+		h := md5.New()
+		if _, err := h.Write(buf.Bytes()); err != nil {
+			mw.err = err
+		}
+		md5Sum = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	p := &Part{Number: mw.partN}
+	mw.parts = append(mw.parts, p)
+	j := &job{
+		r:    bytes.NewReader(buf.Bytes()),
+		len:  int64(buf.Len()),
+		part: p,
+		buf:  buf,
+		md5:  md5Sum,
+	}
+	mw.ch <- j
+
+	// double part size every 1000 parts to avoid exceeding the 10000-part
+	// limit most multipart APIs share, while still reaching a multi-TB
+	// object size.
+	if mw.partN%1000 == 0 {
+		mw.bufsz = min64(mw.bufsz*2, mw.c.MaxPartSize)
+		mw.pool.SetSize(mw.bufsz)
+	}
+}
+
+func (mw *MultipartWriter) worker() {
+	for j := range mw.ch {
+		mw.retryUploadPart(j)
+	}
+}
+
+// retryUploadPart calls UploadPart up to NTry times to recover from
+// transient errors, consulting mw.retryer for whether and how long to
+// wait between attempts. It stops early if ctx is canceled, or if the
+// retryer decides the error isn't retriable (e.g. a 403).
+func (mw *MultipartWriter) retryUploadPart(j *job) {
+	defer mw.wg.Done()
+	var err error
+	for i := 0; i < mw.c.NTry; i++ {
+		if i > 0 {
+			delay, retry := mw.retryer.ShouldRetry(mw.ctx, i, err)
+			if !retry {
+				break
+			}
+			if serr := SleepContext(mw.ctx, delay); serr != nil {
+				err = serr
+				break
+			}
+		}
+		var etag string
+		if _, serr := j.r.Seek(0, io.SeekStart); serr != nil {
+			err = serr
+			continue
+		}
+		etag, err = mw.cw.UploadPart(mw.ctx, j.part.Number, j.r, j.len, j.md5)
+		if err == nil {
+			j.part.ETag = etag
+			mw.pool.Give(j.buf)
+			return
+		}
+	}
+	mw.err = err
+}
+
+// Close flushes any buffered data, waits for all in-flight parts to
+// upload, and completes the multipart upload. On any error the upload
+// is aborted.
+func (mw *MultipartWriter) Close() error {
+	if mw.closed {
+		mw.Abort()
+		return fmt.Errorf("multipart: close on closed MultipartWriter")
+	}
+	if mw.buf != nil && mw.buf.Len() > 0 {
+		mw.flush()
+	}
+	mw.wg.Wait()
+	close(mw.ch)
+	mw.closed = true
+
+	if mw.partN == 0 {
+		mw.Abort()
+		return fmt.Errorf("0 bytes written")
+	}
+	if mw.err != nil {
+		mw.Abort()
+		return mw.err
+	}
+	if err := mw.cw.Complete(mw.ctx, mw.parts); err != nil {
+		mw.Abort()
+		return err
+	}
+	return nil
+}
+
+// Abort cancels the upload via the underlying ChunkWriter. It is safe
+// to call multiple times. It uses context.Background rather than
+// mw.ctx since it runs precisely when mw.ctx may already be canceled.
+func (mw *MultipartWriter) Abort() error {
+	return mw.cw.Abort(context.Background())
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,155 @@
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeChunkWriter is an in-memory ChunkWriter, the kind this package's
+// doc comment promises callers can drop in to unit-test upload logic
+// without talking to any real object store.
+type fakeChunkWriter struct {
+	mu sync.Mutex
+
+	created   bool
+	completed bool
+	aborted   bool
+
+	parts map[int][]byte
+
+	// failUploads, if set, makes the first failUploads calls to
+	// UploadPart fail before succeeding, to exercise retryUploadPart.
+	failUploads int
+	attempts    int
+}
+
+func (f *fakeChunkWriter) CreateUpload(ctx context.Context) error {
+	f.created = true
+	f.parts = make(map[int][]byte)
+	return nil
+}
+
+func (f *fakeChunkWriter) UploadPart(ctx context.Context, n int, r io.ReadSeeker, len int64, md5sum string) (string, error) {
+	f.mu.Lock()
+	f.attempts++
+	attempt := f.attempts
+	f.mu.Unlock()
+
+	if attempt <= f.failUploads {
+		return "", errors.New("fake transient error")
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(b)
+	etag := fmt.Sprintf("%x", sum)
+
+	f.mu.Lock()
+	f.parts[n] = b
+	f.mu.Unlock()
+	return etag, nil
+}
+
+func (f *fakeChunkWriter) Complete(ctx context.Context, parts []*Part) error {
+	f.completed = true
+	return nil
+}
+
+func (f *fakeChunkWriter) Abort(ctx context.Context) error {
+	f.aborted = true
+	return nil
+}
+
+// assembled returns the parts written to cw, concatenated in part-number
+// order, as the resulting object's bytes.
+func (f *fakeChunkWriter) assembled() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var buf bytes.Buffer
+	for n := 1; n <= len(f.parts); n++ {
+		buf.Write(f.parts[n])
+	}
+	return buf.Bytes()
+}
+
+func TestMultipartWriterRoundTrip(t *testing.T) {
+	cw := &fakeChunkWriter{}
+	c := Config{Concurrency: 2, NTry: 1, PartSize: 16, MinPartSize: 16, MaxPartSize: 64}
+	pool := NewSimpleBufferPool(c.PartSize)
+
+	mw, err := NewMultipartWriter(context.Background(), cw, c, pool)
+	if err != nil {
+		t.Fatalf("NewMultipartWriter: %v", err)
+	}
+	if !cw.created {
+		t.Fatal("CreateUpload was not called")
+	}
+
+	want := bytes.Repeat([]byte("abcdefghij"), 10) // 100 bytes in one Write, flushed as a single part
+	if _, err := mw.Write(want); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !cw.completed {
+		t.Fatal("Complete was not called")
+	}
+	if cw.aborted {
+		t.Fatal("Abort was called on a successful upload")
+	}
+	if got := cw.assembled(); !bytes.Equal(got, want) {
+		t.Fatalf("assembled parts = %q, want %q", got, want)
+	}
+}
+
+func TestMultipartWriterRetriesFailedPart(t *testing.T) {
+	cw := &fakeChunkWriter{failUploads: 1}
+	c := Config{Concurrency: 1, NTry: 2, PartSize: 16, MinPartSize: 16, MaxPartSize: 16}
+	pool := NewSimpleBufferPool(c.PartSize)
+
+	mw, err := NewMultipartWriter(context.Background(), cw, c, pool)
+	if err != nil {
+		t.Fatalf("NewMultipartWriter: %v", err)
+	}
+	if _, err := mw.Write(bytes.Repeat([]byte("x"), 16)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close should have succeeded after retry: %v", err)
+	}
+	if cw.attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", cw.attempts)
+	}
+}
+
+func TestMultipartWriterAbortsOnPermanentFailure(t *testing.T) {
+	cw := &fakeChunkWriter{failUploads: 100}
+	c := Config{Concurrency: 1, NTry: 2, PartSize: 16, MinPartSize: 16, MaxPartSize: 16}
+	pool := NewSimpleBufferPool(c.PartSize)
+
+	mw, err := NewMultipartWriter(context.Background(), cw, c, pool)
+	if err != nil {
+		t.Fatalf("NewMultipartWriter: %v", err)
+	}
+	if _, err := mw.Write(bytes.Repeat([]byte("x"), 16)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := mw.Close(); err == nil {
+		t.Fatal("Close should have failed after exhausting retries")
+	}
+	if !cw.aborted {
+		t.Fatal("Abort was not called after a permanent failure")
+	}
+	if cw.completed {
+		t.Fatal("Complete was called despite a failed part")
+	}
+}
@@ -0,0 +1,29 @@
+package multipart
+
+import "bytes"
+
+// simplePool is the default BufferPool: buffers are allocated on demand
+// and discarded on Give, sized to whatever SetSize was last called with.
+// It exists so callers that don't need cross-upload buffer sharing can
+// use MultipartWriter without standing up anything fancier.
+type simplePool struct {
+	size int64
+}
+
+// NewSimpleBufferPool returns a BufferPool that allocates a fresh buffer
+// per Get and drops it on Give, with no reuse across uploads.
+func NewSimpleBufferPool(size int64) BufferPool {
+	return &simplePool{size: size}
+}
+
+func (p *simplePool) Get() *bytes.Buffer {
+	b := new(bytes.Buffer)
+	b.Grow(int(p.size))
+	return b
+}
+
+func (p *simplePool) Give(*bytes.Buffer) {}
+
+func (p *simplePool) SetSize(size int64) { p.size = size }
+
+func (p *simplePool) Close() {}
@@ -0,0 +1,96 @@
+package multipart
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseError is implemented by ChunkWriter errors that carry the HTTP
+// response that produced them. The default Retryer uses it to honor
+// Retry-After and to avoid retrying non-retriable 4xx responses, without
+// requiring ChunkWriter's own methods to return a *http.Response.
+type ResponseError interface {
+	error
+	Response() *http.Response
+}
+
+// Retryer decides whether, and after how long a delay, a failed
+// UploadPart attempt should be retried.
+type Retryer interface {
+	// ShouldRetry is called after attempt (1-based) has failed with err.
+	// It returns how long to wait before the next attempt, and whether
+	// there should be one at all.
+	ShouldRetry(ctx context.Context, attempt int, err error) (delay time.Duration, retry bool)
+}
+
+// defaultRetryer adds full jitter (see
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/),
+// caps the delay, honors Retry-After on 503/SlowDown responses, and
+// refuses to retry non-retriable 4xx responses (anything but 429).
+type defaultRetryer struct {
+	base time.Duration
+	cap  time.Duration
+}
+
+// DefaultRetryer returns the Retryer used when Config.Retryer is nil.
+func DefaultRetryer() Retryer {
+	return &defaultRetryer{base: 100 * time.Millisecond, cap: 30 * time.Second}
+}
+
+func (r *defaultRetryer) ShouldRetry(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	if re, ok := err.(ResponseError); ok {
+		resp := re.Response()
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
+			return 0, false
+		}
+		if d, ok := retryAfter(resp); ok {
+			return d, true
+		}
+	}
+	backoff := r.base << uint(attempt)
+	if backoff <= 0 || backoff > r.cap {
+		backoff = r.cap
+	}
+	return time.Duration(rand.Int63n(int64(backoff))), true
+}
+
+// retryAfter parses a Retry-After header given as a number of seconds,
+// as S3 sends on 503 Slow Down responses.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// SleepContext waits for d, or returns ctx.Err() early if ctx is done
+// first. It is exported for other packages (e.g. s3gof3r's own
+// control-plane retryRequest) that implement the same retry-with-context
+// pattern against a Retryer.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
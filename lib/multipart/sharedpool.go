@@ -0,0 +1,160 @@
+package multipart
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// SharedBufferPool is a process-wide BufferPool: free buffers are shared
+// across every MultipartWriter that was handed the same *SharedBufferPool,
+// so uploading many files concurrently (e.g. a directory tree) does not
+// grow heap use as concurrency × files × part size. Buffers unused for
+// longer than flushAfter are dropped instead of kept around indefinitely,
+// bounding RSS for bursty workloads.
+//
+// If useMmap is set, buffers are backed by anonymous mmap'd pages instead
+// of the Go heap, so large parts don't pressure the garbage collector.
+// mmap support is platform-specific; where it isn't available the pool
+// silently falls back to ordinary heap allocation.
+type SharedBufferPool struct {
+	mu         sync.Mutex
+	size       int64
+	useMmap    bool
+	flushAfter time.Duration
+
+	free     []pooledBuffer
+	backings map[*bytes.Buffer][]byte // mmap backing slice for a given buffer, if any
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+type pooledBuffer struct {
+	buf  *bytes.Buffer
+	idle time.Time
+}
+
+// NewSharedBufferPool returns a BufferPool sized for size-byte buffers,
+// shared across every caller that uses the returned pool. flushAfter
+// bounds how long an idle buffer is kept before being released; pass 0
+// to disable the idle sweep and keep every freed buffer indefinitely.
+func NewSharedBufferPool(size int64, useMmap bool, flushAfter time.Duration) *SharedBufferPool {
+	p := &SharedBufferPool{
+		size:       size,
+		useMmap:    useMmap,
+		flushAfter: flushAfter,
+		backings:   make(map[*bytes.Buffer][]byte),
+		closed:     make(chan struct{}),
+	}
+	if flushAfter > 0 {
+		go p.sweepLoop()
+	}
+	return p
+}
+
+func (p *SharedBufferPool) Get() *bytes.Buffer {
+	p.mu.Lock()
+	for i := len(p.free) - 1; i >= 0; i-- {
+		pb := p.free[i]
+		if int64(pb.buf.Cap()) >= p.size {
+			last := len(p.free) - 1
+			p.free[i] = p.free[last]
+			p.free = p.free[:last]
+			p.mu.Unlock()
+			pb.buf.Reset()
+			return pb.buf
+		}
+	}
+	size := p.size
+	p.mu.Unlock()
+	return p.alloc(size)
+}
+
+func (p *SharedBufferPool) alloc(size int64) *bytes.Buffer {
+	if p.useMmap {
+		if backing, err := mmapAlloc(int(size)); err == nil {
+			buf := bytes.NewBuffer(backing[:0])
+			p.mu.Lock()
+			p.backings[buf] = backing
+			p.mu.Unlock()
+			return buf
+		}
+		// mmap unsupported or exhausted; fall back to the heap rather
+		// than fail the upload.
+	}
+	buf := new(bytes.Buffer)
+	buf.Grow(int(size))
+	return buf
+}
+
+func (p *SharedBufferPool) Give(buf *bytes.Buffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, pooledBuffer{buf: buf, idle: time.Now()})
+}
+
+// SetSize adjusts the size of buffers handed out by future Gets. Buffers
+// already on loan, or sitting in the free list below the new size, are
+// left alone; they're simply too small to satisfy a subsequent Get and
+// are reclaimed by the idle sweep once unused for longer than flushAfter
+// (never, if flushAfter is 0).
+func (p *SharedBufferPool) SetSize(size int64) {
+	p.mu.Lock()
+	p.size = size
+	p.mu.Unlock()
+}
+
+// Close stops the idle sweep and releases every free buffer's mmap
+// backing, if any. Buffers still on loan are left for the garbage
+// collector or, if mmap-backed, leaked until the process exits; callers
+// should Give every buffer they Get before closing the pool.
+func (p *SharedBufferPool) Close() {
+	p.closeOnce.Do(func() { close(p.closed) })
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pb := range p.free {
+		p.freeBacking(pb.buf)
+	}
+	p.free = nil
+}
+
+func (p *SharedBufferPool) sweepLoop() {
+	t := time.NewTicker(p.flushAfter)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case now := <-t.C:
+			p.sweep(now)
+		}
+	}
+}
+
+func (p *SharedBufferPool) sweep(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	live := p.free[:0]
+	for _, pb := range p.free {
+		if now.Sub(pb.idle) > p.flushAfter {
+			p.freeBacking(pb.buf)
+			continue
+		}
+		live = append(live, pb)
+	}
+	p.free = live
+}
+
+// freeBacking releases the mmap backing for buf, if it has one. Callers
+// must hold p.mu.
+func (p *SharedBufferPool) freeBacking(buf *bytes.Buffer) {
+	backing, ok := p.backings[buf]
+	if !ok {
+		return
+	}
+	delete(p.backings, buf)
+	// best-effort: a failed munmap just leaks the mapping, which is no
+	// worse than never having pooled the buffer at all.
+	_ = mmapFree(backing)
+}
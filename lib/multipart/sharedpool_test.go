@@ -0,0 +1,33 @@
+package multipart
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSharedBufferPoolGetKeepsOtherFreeBuffers guards against Get
+// discarding free-list entries it merely scanned past (and didn't
+// match) while looking for a same-or-larger buffer from the tail.
+func TestSharedBufferPoolGetKeepsOtherFreeBuffers(t *testing.T) {
+	p := NewSharedBufferPool(64, false, 0)
+	p.Give(newCapBuffer(64))
+	big := newCapBuffer(200)
+	wantCap := big.Cap()
+	p.Give(big)
+	p.Give(newCapBuffer(64))
+
+	p.SetSize(150)
+	got := p.Get()
+	if c := got.Cap(); c != wantCap {
+		t.Fatalf("Get() returned a buffer of cap %d, want %d", c, wantCap)
+	}
+	if n := len(p.free); n != 2 {
+		t.Fatalf("len(p.free) = %d after Get, want 2 (the two cap-64 buffers scanned past the match)", n)
+	}
+}
+
+func newCapBuffer(capacity int) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	buf.Grow(capacity)
+	return buf
+}
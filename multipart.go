@@ -0,0 +1,180 @@
+package s3gof3r
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/s3gof3r/lib/multipart"
+)
+
+// MultipartUpload describes an in-progress multipart upload as returned
+// by ListMultipartUploads. UploadID is required to resume or abort it.
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name             `xml:"ListMultipartUploadsResult"`
+	Upload             []multipartUploadXML `xml:"Upload"`
+	IsTruncated        bool
+	NextKeyMarker      string
+	NextUploadIdMarker string
+}
+
+type multipartUploadXML struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+// ListMultipartUploads lists in-progress multipart uploads for the bucket,
+// optionally restricted to keys beginning with prefix. delim is passed to
+// S3 as the delimiter; uploads S3 rolls up into common prefixes are not
+// returned.
+// It is intended to find uploads orphaned by crashed processes, which
+// otherwise leak storage charges since they are never completed or aborted.
+func (b *Bucket) ListMultipartUploads(ctx context.Context, prefix, delim string) ([]MultipartUpload, error) {
+	var uploads []MultipartUpload
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		v := url.Values{}
+		v.Set("uploads", "")
+		if prefix != "" {
+			v.Set("prefix", prefix)
+		}
+		if delim != "" {
+			v.Set("delimiter", delim)
+		}
+		if keyMarker != "" {
+			v.Set("key-marker", keyMarker)
+		}
+		if uploadIDMarker != "" {
+			v.Set("upload-id-marker", uploadIDMarker)
+		}
+		u, err := b.url("")
+		if err != nil {
+			return nil, err
+		}
+		resp, err := retryRequest(ctx, b.Config, b, "GET", u.String()+"?"+v.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			err = wrapRespErr(resp)
+			checkClose(resp.Body, &err)
+			return nil, err
+		}
+		var result listMultipartUploadsResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		checkClose(resp.Body, &err)
+		if err != nil {
+			return nil, err
+		}
+		for _, up := range result.Upload {
+			uploads = append(uploads, MultipartUpload{up.Key, up.UploadId, up.Initiated})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIdMarker
+	}
+	return uploads, nil
+}
+
+// AbortMultipart aborts the multipart upload identified by key and uploadID,
+// freeing the storage already consumed by its uploaded parts.
+func (b *Bucket) AbortMultipart(ctx context.Context, key, uploadID string) (err error) {
+	u, err := b.url(key)
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("uploadId", uploadID)
+	resp, err := retryRequest(ctx, b.Config, b, "DELETE", u.String()+"?"+v.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer checkClose(resp.Body, &err)
+	if resp.StatusCode != 204 {
+		return wrapRespErr(resp)
+	}
+	return nil
+}
+
+// listPartsResult is the body of a GET ?uploadId=... request, used by
+// ResumePutter to discover which parts have already been uploaded.
+type listPartsResult struct {
+	XMLName  xml.Name `xml:"ListPartsResult"`
+	UploadId string
+	Part     []listPart
+}
+
+type listPart struct {
+	PartNumber int
+	ETag       string
+	Size       int64
+}
+
+// ResumePutter resumes a multipart upload previously begun against key,
+// picking up streaming at the next part boundary instead of starting a new
+// upload. uploadID identifies the in-progress upload, typically discovered
+// via ListMultipartUploads after a crashed process left it orphaned.
+//
+// Because the whole-object MD5 cannot be reconstructed from the parts'
+// ETags alone, c.Md5Check is disabled for the resumed putter; only the
+// md5-of-part-md5s check against S3's ETag on Close is performed.
+func (b *Bucket) ResumePutter(ctx context.Context, key, uploadID string, h http.Header, c *Config) (p *putter, err error) {
+	u, err := b.url(key)
+	if err != nil {
+		return nil, err
+	}
+	c.Concurrency = max(c.Concurrency, 1)
+	c.NTry = max(c.NTry, 1)
+	c.Md5Check = false
+
+	cw := &s3ChunkWriter{url: u, b: b, c: c, h: h, UploadId: uploadID, md5OfParts: md5.New()}
+
+	v := url.Values{}
+	v.Set("uploadId", uploadID)
+	resp, err := cw.retryRequest(ctx, "GET", u.String()+"?"+v.Encode(), nil, h)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		err = wrapRespErr(resp)
+		checkClose(resp.Body, &err)
+		return nil, err
+	}
+	var result listPartsResult
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	checkClose(resp.Body, &err)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*multipart.Part
+	for _, lp := range result.Part {
+		etag := strings.Trim(lp.ETag, `"`)
+		sum, err := hex.DecodeString(etag)
+		if err != nil {
+			return nil, fmt.Errorf("resume: could not decode etag %q for part %d: %v", lp.ETag, lp.PartNumber, err)
+		}
+		if _, err = cw.md5OfParts.Write(sum); err != nil {
+			return nil, err
+		}
+		parts = append(parts, &multipart.Part{Number: lp.PartNumber, ETag: etag})
+	}
+
+	mw := multipart.ResumeMultipartWriter(ctx, cw, parts, multipartConfig(c), bufferPool(c))
+
+	return &putter{ctx: ctx, cw: cw, mw: mw, md5: md5.New()}, nil
+}
@@ -2,20 +2,21 @@ package s3gof3r
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"hash"
 	"io"
-	"math"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
+
+	"github.com/AlexAkulov/s3gof3r/lib/multipart"
 )
 
 // defined by amazon
@@ -27,324 +28,353 @@ const (
 	md5Header   = "content-md5"
 )
 
-type part struct {
-	r   io.ReadSeeker
-	len int64
-	b   *bytes.Buffer
-
-	// read by xml encoder
-	PartNumber int
-	ETag       string
+// completePart is one entry in the CompleteMultipartUpload request body,
+// matching S3's expected XML element names. The checksum fields are only
+// populated when Config.ChecksumAlgorithm is set.
+type completePart struct {
+	PartNumber     int
+	ETag           string
+	ChecksumCRC32C string `xml:",omitempty"`
+	ChecksumSHA256 string `xml:",omitempty"`
+}
 
-	// Used for checksum of checksums on completion
-	contentMd5 string
+// setChecksum records sum, S3's response trailer for this part, under
+// the XML field matching algorithm.
+func (p *completePart) setChecksum(algorithm, sum string) {
+	switch algorithm {
+	case ChecksumCRC32C:
+		p.ChecksumCRC32C = sum
+	case ChecksumSHA256:
+		p.ChecksumSHA256 = sum
+	}
 }
 
+// putter is an io.WriteCloser that uploads to S3 via multipart.MultipartWriter,
+// using s3ChunkWriter as the S3-specific ChunkWriter implementation. It adds
+// the one bit that is specific to this package's public API: the optional
+// whole-object MD5 sidecar file.
 type putter struct {
-	url url.URL
-	b   *Bucket
-	c   *Config
-
-	bufsz      int64
-	buf        *bytes.Buffer
-	ch         chan *part
-	part       int
-	closed     bool
-	err        error
-	wg         sync.WaitGroup
-	md5OfParts hash.Hash
-	md5        hash.Hash
-	ETag       string
-
-	bp *bp
+	ctx context.Context
+	cw  *s3ChunkWriter
+	mw  *multipart.MultipartWriter
 
-	makes    int
-	UploadId string // casing matches s3 xml
-	xml      struct {
-		XMLName string `xml:"CompleteMultipartUpload"`
-		Part    []*part
-	}
+	md5 hash.Hash // whole-object hash, for the optional .md5 sidecar
 }
 
 // Sends an S3 multipart upload initiation request.
 // See http://docs.amazonwebservices.com/AmazonS3/latest/dev/mpuoverview.html.
 // The initial request returns an UploadId that we use to identify
 // subsequent PUT requests.
-func newPutter(url url.URL, h http.Header, c *Config, b *Bucket) (p *putter, err error) {
-	p = new(putter)
-	p.url = url
-	p.b = b
-	p.c = c
-	p.c.Concurrency = max(c.Concurrency, 1)
-	p.c.NTry = max(c.NTry, 1)
-	p.bufsz = max64(minPartSize, c.PartSize)
-	resp, err := p.retryRequest("POST", url.String()+"?uploads", nil, h)
-	if err != nil {
-		return nil, err
-	}
-	defer checkClose(resp.Body, &err)
-	if resp.StatusCode != 200 {
-		return nil, newRespError(resp)
-	}
-	err = xml.NewDecoder(resp.Body).Decode(p)
+func newPutter(ctx context.Context, u url.URL, h http.Header, c *Config, b *Bucket) (p *putter, err error) {
+	c.Concurrency = max(c.Concurrency, 1)
+	c.NTry = max(c.NTry, 1)
+	cw := &s3ChunkWriter{url: u, b: b, c: c, h: h, md5OfParts: md5.New()}
+	mw, err := multipart.NewMultipartWriter(ctx, cw, multipartConfig(c), bufferPool(c))
 	if err != nil {
 		return nil, err
 	}
-	p.ch = make(chan *part)
-	for i := 0; i < p.c.Concurrency; i++ {
-		go p.worker()
-	}
-	p.md5OfParts = md5.New()
-	p.md5 = md5.New()
+	return &putter{ctx: ctx, cw: cw, mw: mw, md5: md5.New()}, nil
+}
 
-	p.bp = newBufferPool(p.bufsz)
+// multipartConfig translates the subset of Config the multipart engine
+// needs into its own Config type, so lib/multipart doesn't depend on
+// s3gof3r's (and so other ChunkWriters aren't forced to either).
+func multipartConfig(c *Config) multipart.Config {
+	return multipart.Config{
+		Concurrency: c.Concurrency,
+		NTry:        c.NTry,
+		PartSize:    c.PartSize,
+		MinPartSize: minPartSize,
+		MaxPartSize: maxPartSize,
+		Retryer:     c.Retryer,
+		// A trailing checksum (see checksum.go) stands in for the
+		// pre-computed Content-MD5 flush would otherwise build.
+		SkipMD5: c.ChecksumAlgorithm != "" && c.SignatureVersion != SignatureV2,
+	}
+}
 
-	return p, nil
+// bufferPool returns c.BufferPool if the caller set one — letting many
+// concurrent putters share buffers, and optionally back them with mmap'd
+// pages instead of the Go heap — or a private, per-putter pool otherwise.
+func bufferPool(c *Config) multipart.BufferPool {
+	if c.BufferPool != nil {
+		return c.BufferPool
+	}
+	return multipart.NewSimpleBufferPool(max64(minPartSize, c.PartSize))
 }
 
 func (p *putter) Write(b []byte) (int, error) {
-	if p.closed {
-		p.abort()
-		return 0, syscall.EINVAL
-	}
-	if p.err != nil {
-		p.abort()
-		return 0, p.err
-	}
-	if p.buf == nil {
-		p.buf = <-p.bp.get
-		// grow to bufsz, allocating overhead to avoid slice growth
-		p.buf.Grow(int(p.bufsz + 100*kb))
-	}
-	n, err := p.buf.Write(b)
+	n, err := p.mw.Write(b)
 	if err != nil {
-		p.abort()
 		return n, err
 	}
-
-	if int64(p.buf.Len()) >= p.bufsz {
-		p.flush()
+	if _, err := p.md5.Write(b[:n]); err != nil {
+		return n, err
 	}
 	return n, nil
 }
 
-func (p *putter) flush() {
-	p.wg.Add(1)
-	p.part++
-	b := *p.buf
-	part := &part{bytes.NewReader(b.Bytes()), int64(b.Len()), p.buf, p.part, "", ""}
-	var err error
-	part.contentMd5, part.ETag, err = p.md5Content(part.r)
+func (p *putter) Close() (err error) {
+	if err = p.mw.Close(); err != nil {
+		return err
+	}
+	if p.cw.c.Md5Check {
+		return p.putMd5()
+	}
+	return nil
+}
+
+// Put md5 file in .md5 subdirectory of bucket  where the file is stored
+// e.g. the md5 for https://mybucket.s3.amazonaws.com/gof3r will be stored in
+// https://mybucket.s3.amazonaws.com/.md5/gof3r.md5
+func (p *putter) putMd5() (err error) {
+	calcMd5 := fmt.Sprintf("%x", p.md5.Sum(nil))
+	md5Reader := strings.NewReader(calcMd5)
+	md5Path := fmt.Sprint(".md5", p.cw.url.Path, ".md5")
+	md5Url, err := p.cw.b.url(md5Path)
+	if err != nil {
+		return err
+	}
+	logger.debugPrintln("md5: ", calcMd5)
+	logger.debugPrintln("md5Path: ", md5Path)
+	r, err := http.NewRequestWithContext(p.ctx, "PUT", md5Url.String(), md5Reader)
 	if err != nil {
-		p.err = err
+		return
+	}
+	if err = signRequest(p.cw.c, p.cw.b, r, strings.NewReader(calcMd5)); err != nil {
+		return
+	}
+	resp, err := p.cw.c.Client.Do(r)
+	if err != nil {
+		return
+	}
+	defer checkClose(resp.Body, &err)
+	if resp.StatusCode != 200 {
+		return wrapRespErr(resp)
 	}
+	return nil
+}
 
-	p.xml.Part = append(p.xml.Part, part)
-	p.ch <- part
-	p.buf = nil
-	// double buffer size every 1000 parts to
-	// avoid exceeding the 10000-part AWS limit
-	// while still reaching the 5 Terabyte max object size
-	if p.part%1000 == 0 {
-		p.bufsz = min64(p.bufsz*2, maxPartSize)
-		p.bp.makeSize = p.bufsz
-		logger.debugPrintf("part size doubled to %d", p.bufsz)
+// s3ChunkWriter is the S3 adapter satisfying multipart.ChunkWriter. It owns
+// everything specific to talking to S3: request signing, retries, and the
+// md5-of-part-md5s check against the ETag S3 returns on completion. Other
+// object stores can be supported by implementing the same interface.
+type s3ChunkWriter struct {
+	url url.URL
+	b   *Bucket
+	c   *Config
+	h   http.Header // extra headers sent on CreateUpload
 
-	}
+	UploadId   string
+	md5OfParts hash.Hash // hash of each part's raw MD5, checked against ETag on Complete
+	ETag       string
 
+	// checksumsMu guards partChecksums, which UploadPart populates from
+	// concurrent worker goroutines when c.ChecksumAlgorithm is set.
+	checksumsMu   sync.Mutex
+	partChecksums map[int]string
 }
 
-func (p *putter) worker() {
-	for part := range p.ch {
-		p.retryPutPart(part)
+func (cw *s3ChunkWriter) CreateUpload(ctx context.Context) (err error) {
+	h := cw.h.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	cw.c.Encryption.setHeaders(h)
+	resp, err := cw.retryRequest(ctx, "POST", cw.url.String()+"?uploads", nil, h)
+	if err != nil {
+		return err
 	}
+	defer checkClose(resp.Body, &err)
+	if resp.StatusCode != 200 {
+		return wrapRespErr(resp)
+	}
+	return xml.NewDecoder(resp.Body).Decode(cw)
 }
 
-// Calls putPart up to nTry times to recover from transient errors.
-func (p *putter) retryPutPart(part *part) {
-	defer p.wg.Done()
-	var err error
-	for i := 0; i < p.c.NTry; i++ {
-		time.Sleep(time.Duration(math.Exp2(float64(i))) * 100 * time.Millisecond) // exponential back-off
-		err = p.putPart(part)
-		if err == nil {
-			p.bp.give <- part.b
-			return
-		}
-		logger.debugPrintf("Error on attempt %d: Retrying part: %v, Error: %s", i, part, err)
-	}
-	p.err = err
+// trailingChecksum reports whether UploadPart should send a trailing
+// checksum instead of a pre-computed Content-MD5, per Config.ChecksumAlgorithm.
+func (cw *s3ChunkWriter) trailingChecksum() bool {
+	return cw.c.ChecksumAlgorithm != "" && cw.c.SignatureVersion != SignatureV2
 }
 
-// uploads a part, checking the etag against the calculated value
-func (p *putter) putPart(part *part) error {
+// UploadPart uploads a part, checking the etag against the calculated value.
+func (cw *s3ChunkWriter) UploadPart(ctx context.Context, n int, r io.ReadSeeker, size int64, md5Sum string) (etag string, err error) {
+	if !cw.trailingChecksum() {
+		sum, err := base64.StdEncoding.DecodeString(md5Sum)
+		if err != nil {
+			return "", err
+		}
+		if _, err = cw.md5OfParts.Write(sum); err != nil {
+			return "", err
+		}
+	}
 	v := url.Values{}
-	v.Set("partNumber", strconv.Itoa(part.PartNumber))
-	v.Set("uploadId", p.UploadId)
-	if _, err := part.r.Seek(0, 0); err != nil { // move back to beginning, if retrying
-		return err
+	v.Set("partNumber", strconv.Itoa(n))
+	v.Set("uploadId", cw.UploadId)
+	if _, err = r.Seek(0, 0); err != nil {
+		return "", err
 	}
-	req, err := http.NewRequest("PUT", p.url.String()+"?"+v.Encode(), part.r)
+	req, err := http.NewRequestWithContext(ctx, "PUT", cw.url.String()+"?"+v.Encode(), nil)
 	if err != nil {
-		return err
-	}
-	req.ContentLength = part.len
-	req.Header.Set(md5Header, part.contentMd5)
-	p.b.Sign(req)
-	resp, err := p.c.Client.Do(req)
+		return "", err
+	}
+	cw.c.Encryption.setHeaders(req.Header)
+	signer := newV4Signer(cw.b.AccessKey, cw.b.SecretKey, regionFromHost(req.URL.Host), time.Now().UTC())
+	switch {
+	case cw.c.SignatureVersion == SignatureV2:
+		req.Header.Set(md5Header, md5Sum)
+		req.ContentLength = size
+		req.Body = io.NopCloser(r)
+		cw.b.Sign(req)
+	case cw.trailingChecksum():
+		req.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(size, 10))
+		req.Header.Set("x-amz-trailer", checksumTrailerHeader(cw.c.ChecksumAlgorithm))
+		req.Header.Set("Transfer-Encoding", "aws-chunked")
+		req.ContentLength = trailerBodyEncodedLength(size, cw.c.ChecksumAlgorithm)
+		signer.sign(req, streamingUnsignedPayloadTrailer)
+		req.Body = io.NopCloser(newTrailerBody(r, size, cw.c.ChecksumAlgorithm))
+	default:
+		req.Header.Set(md5Header, md5Sum)
+		req.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(size, 10))
+		req.ContentLength = streamingBodyEncodedLength(size)
+		seed := signer.sign(req, streamingPayload)
+		req.Body = io.NopCloser(newStreamingBody(r, signer, req.Header.Get("x-amz-date"), seed))
+	}
+	resp, err := cw.c.Client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer checkClose(resp.Body, &err)
 	if resp.StatusCode != 200 {
-		return newRespError(resp)
+		return "", wrapRespErr(resp)
+	}
+	if cw.trailingChecksum() {
+		sum := resp.Header.Get(checksumTrailerHeader(cw.c.ChecksumAlgorithm))
+		cw.checksumsMu.Lock()
+		if cw.partChecksums == nil {
+			cw.partChecksums = make(map[int]string)
+		}
+		cw.partChecksums[n] = sum
+		cw.checksumsMu.Unlock()
 	}
 	s := resp.Header.Get("etag")
 	s = s[1 : len(s)-1] // includes quote chars for some reason
-	if part.ETag != s {
-		return fmt.Errorf("Response etag does not match. Remote:%s Calculated:%s", s, p.ETag)
+	// SSE-KMS and SSE-C parts have opaque, non-MD5 ETags, and a trailing
+	// checksum part has no md5Sum to compare against.
+	if !cw.trailingChecksum() && !cw.c.Encryption.skipMd5OfParts() {
+		sum, _ := base64.StdEncoding.DecodeString(md5Sum) // decoded without error above
+		if calc := fmt.Sprintf("%x", sum); calc != s {
+			return "", fmt.Errorf("Response etag does not match. Remote:%s Calculated:%s", s, calc)
+		}
 	}
-	return nil
+	return s, nil
 }
 
-func (p *putter) Close() (err error) {
-	if p.closed {
-		p.abort()
-		return syscall.EINVAL
-	}
-	if p.buf != nil {
-		buf := *p.buf
-		if buf.Len() > 0 {
-			p.flush()
+func (cw *s3ChunkWriter) Complete(ctx context.Context, parts []*multipart.Part) (err error) {
+	var body struct {
+		XMLName      string `xml:"CompleteMultipartUpload"`
+		Part         []completePart
+		ChecksumType string `xml:",omitempty"`
+	}
+	for _, part := range parts {
+		cp := completePart{PartNumber: part.Number, ETag: part.ETag}
+		if cw.trailingChecksum() {
+			cw.checksumsMu.Lock()
+			cp.setChecksum(cw.c.ChecksumAlgorithm, cw.partChecksums[part.Number])
+			cw.checksumsMu.Unlock()
 		}
+		body.Part = append(body.Part, cp)
 	}
-	p.wg.Wait()
-	close(p.ch)
-	p.closed = true
-	close(p.bp.quit)
-
-	if p.part == 0 {
-		p.abort()
-		return fmt.Errorf("0 bytes written")
-	}
-	if p.err != nil {
-		p.abort()
-		return p.err
+	if cw.trailingChecksum() {
+		body.ChecksumType = "FULL_OBJECT"
 	}
-	// Complete Multipart upload
-	body, err := xml.Marshal(p.xml)
+	b, err := xml.Marshal(body)
 	if err != nil {
-		p.abort()
-		return
+		return err
 	}
-	b := bytes.NewReader(body)
 	v := url.Values{}
-	v.Set("uploadId", p.UploadId)
-	resp, err := p.retryRequest("POST", p.url.String()+"?"+v.Encode(), b, nil)
+	v.Set("uploadId", cw.UploadId)
+	resp, err := cw.retryRequest(ctx, "POST", cw.url.String()+"?"+v.Encode(), bytes.NewReader(b), nil)
 	if err != nil {
-		p.abort()
-		return
+		return err
 	}
 	defer checkClose(resp.Body, &err)
 	if resp.StatusCode != 200 {
-		p.abort()
-		return newRespError(resp)
+		return wrapRespErr(resp)
 	}
 	// Check md5 hash of concatenated part md5 hashes against ETag
 	// more info: https://forums.aws.amazon.com/thread.jspa?messageID=456442&#456442
-	calculatedMd5ofParts := fmt.Sprintf("%x", p.md5OfParts.Sum(nil))
-	// Parse etag from body of response
-	err = xml.NewDecoder(resp.Body).Decode(p)
-	if err != nil {
-		return
+	//
+	// S3 returns an opaque, non-MD5 ETag for SSE-KMS and SSE-C objects, so
+	// this check is skipped for those (see EncryptionConfig.skipMd5OfParts).
+	// It is likewise skipped when a trailing checksum was used instead of
+	// Content-MD5: S3 already verified each part's checksum as it arrived,
+	// and ChecksumType: FULL_OBJECT above has it verify end-to-end, so
+	// there is nothing left for this hash-of-hashes check to catch.
+	calculatedMd5ofParts := fmt.Sprintf("%x", cw.md5OfParts.Sum(nil))
+	if err = xml.NewDecoder(resp.Body).Decode(cw); err != nil {
+		return err
+	}
+	if cw.c.Encryption.skipMd5OfParts() || cw.trailingChecksum() {
+		return nil
 	}
 	// strip part count from end and '"' from front.
-	remoteMd5ofParts := strings.Split(p.ETag, "-")[0]
+	remoteMd5ofParts := strings.Split(cw.ETag, "-")[0]
 	remoteMd5ofParts = remoteMd5ofParts[1:len(remoteMd5ofParts)]
 	if calculatedMd5ofParts != remoteMd5ofParts {
-		if err != nil {
-			return err
-		}
 		return fmt.Errorf("MD5 hash of part hashes comparison failed. Hash from multipart complete header: %s."+
 			" Calculated multipart hash: %s.", remoteMd5ofParts, calculatedMd5ofParts)
 	}
-	if p.c.Md5Check {
-		for i := 0; i < p.c.NTry; i++ {
-			if err = p.putMd5(); err == nil {
-				break
-			}
-		}
-		return
-	}
-	return
+	return nil
 }
 
-// Try to abort multipart upload. Do not error on failure.
-func (p *putter) abort() {
+// Abort tries to abort the multipart upload. It does not error on failure,
+// mirroring the original putter's best-effort cleanup. It always uses
+// context.Background, since it typically runs after ctx has already been
+// canceled (that's often why an upload is being aborted at all).
+func (cw *s3ChunkWriter) Abort(ctx context.Context) error {
 	v := url.Values{}
-	v.Set("uploadId", p.UploadId)
-	s := p.url.String() + "?" + v.Encode()
-	resp, err := p.retryRequest("DELETE", s, nil, nil)
+	v.Set("uploadId", cw.UploadId)
+	s := cw.url.String() + "?" + v.Encode()
+	resp, err := cw.retryRequest(context.Background(), "DELETE", s, nil, nil)
 	if err != nil {
 		logger.Printf("Error aborting multipart upload: %v\n", err)
-		return
+		return nil
 	}
 	defer checkClose(resp.Body, &err)
 	if resp.StatusCode != 204 {
-		logger.Printf("Error aborting multipart upload: %v", newRespError(resp))
+		logger.Printf("Error aborting multipart upload: %v", wrapRespErr(resp))
 	}
-	return
+	return nil
 }
 
-// Md5 functions
-func (p *putter) md5Content(r io.ReadSeeker) (string, string, error) {
-	h := md5.New()
-	mw := io.MultiWriter(h, p.md5)
-	if _, err := io.Copy(mw, r); err != nil {
-		return "", "", err
-	}
-	sum := h.Sum(nil)
-	hexSum := fmt.Sprintf("%x", sum)
-	// add to checksum of all parts for verification on upload completion
-	if _, err := p.md5OfParts.Write(sum); err != nil {
-		return "", "", err
-	}
-	return base64.StdEncoding.EncodeToString(sum), hexSum, nil
+func (cw *s3ChunkWriter) retryRequest(ctx context.Context, method, urlStr string, body io.ReadSeeker, h http.Header) (resp *http.Response, err error) {
+	return retryRequest(ctx, cw.c, cw.b, method, urlStr, body, h)
 }
 
-// Put md5 file in .md5 subdirectory of bucket  where the file is stored
-// e.g. the md5 for https://mybucket.s3.amazonaws.com/gof3r will be stored in
-// https://mybucket.s3.amazonaws.com/.md5/gof3r.md5
-func (p *putter) putMd5() (err error) {
-	calcMd5 := fmt.Sprintf("%x", p.md5.Sum(nil))
-	md5Reader := strings.NewReader(calcMd5)
-	md5Path := fmt.Sprint(".md5", p.url.Path, ".md5")
-	md5Url, err := p.b.url(md5Path)
-	if err != nil {
-		return err
-	}
-	logger.debugPrintln("md5: ", calcMd5)
-	logger.debugPrintln("md5Path: ", md5Path)
-	r, err := http.NewRequest("PUT", md5Url.String(), md5Reader)
-	if err != nil {
-		return
-	}
-	p.b.Sign(r)
-	resp, err := p.c.Client.Do(r)
-	if err != nil {
-		return
-	}
-	defer checkClose(resp.Body, &err)
-	if resp.StatusCode != 200 {
-		return newRespError(resp)
-	}
-	return
-}
-
-func (p *putter) retryRequest(method, urlStr string, body io.ReadSeeker, h http.Header) (resp *http.Response, err error) {
-	for i := 0; i < p.c.NTry; i++ {
+// retryRequest issues an HTTP request, retrying per c.Retryer on transient
+// (network) errors as well as on retriable HTTP statuses (429 and 5xx,
+// including 503 Slow Down), re-signing and rewinding body on each attempt.
+// It is shared by s3ChunkWriter and Bucket's own administrative calls
+// (listing and aborting multipart uploads).
+func retryRequest(ctx context.Context, c *Config, b *Bucket, method, urlStr string, body io.ReadSeeker, h http.Header) (resp *http.Response, err error) {
+	retryer := c.Retryer
+	if retryer == nil {
+		retryer = multipart.DefaultRetryer()
+	}
+	for i := 0; i < c.NTry; i++ {
+		if i > 0 {
+			delay, retry := retryer.ShouldRetry(ctx, i, err)
+			if !retry {
+				return nil, err
+			}
+			if serr := multipart.SleepContext(ctx, delay); serr != nil {
+				return nil, serr
+			}
+		}
 		var req *http.Request
-		req, err = http.NewRequest(method, urlStr, body)
+		req, err = http.NewRequestWithContext(ctx, method, urlStr, body)
 		if err != nil {
 			return
 		}
@@ -353,18 +383,49 @@ func (p *putter) retryRequest(method, urlStr string, body io.ReadSeeker, h http.
 				req.Header.Add(k, v)
 			}
 		}
-
-		p.b.Sign(req)
-		resp, err = p.c.Client.Do(req)
-		if err == nil {
+		if err = signRequest(c, b, req, body); err != nil {
 			return
 		}
+		resp, err = c.Client.Do(req)
+		if err == nil {
+			if !retriableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			err = wrapRespErr(resp)
+			checkClose(resp.Body, &err)
+			resp = nil
+		}
 		logger.debugPrintln(err)
 		if body != nil {
-			if _, err = body.Seek(0, 0); err != nil {
-				return
+			if _, serr := body.Seek(0, 0); serr != nil {
+				return nil, serr
 			}
 		}
 	}
 	return
 }
+
+// retriableStatus reports whether an HTTP response status is one
+// retryRequest's control-plane calls should retry rather than hand
+// straight back to the caller, mirroring the policy UploadPart's own
+// retry already applies via defaultRetryer.ShouldRetry: 429 (throttling)
+// and 5xx (including 503 Slow Down). Other non-2xx statuses are left for
+// callers to interpret themselves, as they already do via wrapRespErr.
+func retriableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// wrappedRespError pairs an S3 error response's parsed error with the
+// *http.Response itself, implementing multipart.ResponseError so
+// Retryers can honor Retry-After and tell non-retriable 4xx responses
+// (e.g. 403) apart from ones worth retrying (e.g. 503 Slow Down).
+type wrappedRespError struct {
+	error
+	resp *http.Response
+}
+
+func (e *wrappedRespError) Response() *http.Response { return e.resp }
+
+func wrapRespErr(resp *http.Response) error {
+	return &wrappedRespError{error: newRespError(resp), resp: resp}
+}
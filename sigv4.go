@@ -0,0 +1,274 @@
+package s3gof3r
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// signRequest signs req according to c.SignatureVersion, defaulting to
+// SigV4. body, if non-nil, is read in full to compute its payload hash
+// and then rewound; this is only used for the small, in-memory control
+// requests (initiate/complete/abort, the .md5 sidecar PUT), not for part
+// uploads, which use the streaming signer in s3ChunkWriter.UploadPart.
+func signRequest(c *Config, b *Bucket, req *http.Request, body io.ReadSeeker) error {
+	if c.SignatureVersion == SignatureV2 {
+		b.Sign(req)
+		return nil
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		if _, err := body.Seek(0, 0); err != nil {
+			return err
+		}
+	}
+	signer := newV4Signer(b.AccessKey, b.SecretKey, regionFromHost(req.URL.Host), time.Now().UTC())
+	signer.sign(req, sha256Hex(payload))
+	return nil
+}
+
+// AWS Signature Version 4, including the streaming chunked-signing variant
+// used for multipart part uploads. SigV4 is required in every region
+// added since 2014 (eu-central-1, ap-*, ...) and by S3-compatible stores
+// that have dropped V2 (MinIO, Ceph RGW, Cloudflare R2).
+//
+// See http://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html and
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html.
+
+// Values for Config.SignatureVersion.
+const (
+	SignatureV2 = "v2"
+	SignatureV4 = "v4"
+)
+
+const (
+	sigV4Algorithm    = "AWS4-HMAC-SHA256"
+	sigV4ChunkPayload = "AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayload  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// streamingUnsignedPayloadTrailer marks a request whose body carries
+	// an x-amz-trailer checksum (see checksum.go) instead of per-chunk
+	// signatures: only the request's headers are SigV4-signed.
+	streamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	sigV4Service                    = "s3"
+	sigV4ChunkSize                  = 8 * kb
+	iso8601Basic                    = "20060102T150405Z"
+	iso8601Date                     = "20060102"
+)
+
+// emptySha256 is the SHA-256 hex digest of a zero-length payload, used as
+// the fixed hash line in every chunk's string-to-sign; only the previous
+// signature and the chunk's own data hash change from chunk to chunk.
+var emptySha256 = sha256Hex(nil)
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// regionFromHost derives the AWS region from an S3 endpoint hostname,
+// falling back to us-east-1 for the classic global endpoint and for
+// hosts that don't follow the s3.<region>.amazonaws.com convention
+// (e.g. third-party S3-compatible stores), where the caller is expected
+// to know their region out of band.
+func regionFromHost(host string) string {
+	parts := strings.Split(host, ".")
+	for i, p := range parts {
+		if p == "s3" && i+2 < len(parts) && parts[i+2] == "amazonaws" {
+			return parts[i+1]
+		}
+	}
+	return "us-east-1"
+}
+
+// v4Signer computes AWS Signature Version 4 signatures for a single
+// request, including the chain of chunk signatures used by the
+// streaming payload variant.
+type v4Signer struct {
+	accessKey, secretKey, region string
+	t                            time.Time
+	key                          []byte // derived signing key, memoized per date+region
+}
+
+func newV4Signer(accessKey, secretKey, region string, t time.Time) *v4Signer {
+	date := t.Format(iso8601Date)
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(date))
+	kRegion := hmacSHA256(kDate, []byte(region))
+	kService := hmacSHA256(kRegion, []byte(sigV4Service))
+	key := hmacSHA256(kService, []byte("aws4_request"))
+	return &v4Signer{accessKey: accessKey, secretKey: secretKey, region: region, t: t, key: key}
+}
+
+func (s *v4Signer) credentialScope() string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", s.t.Format(iso8601Date), s.region, sigV4Service)
+}
+
+func (s *v4Signer) canonicalRequest(req *http.Request, payloadHash string) (canonical, signedHeaders string) {
+	var headerNames []string
+	for k := range req.Header {
+		// Authorization must never be signed over: once sign() has run
+		// once on a request, it's already present on req.Header, and
+		// including it here would mean signing over the signature
+		// itself on any later re-canonicalization of the same request.
+		if strings.EqualFold(k, "Authorization") {
+			continue
+		}
+		headerNames = append(headerNames, strings.ToLower(k))
+	}
+	headerNames = append(headerNames, "host")
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		var value string
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		} else {
+			value = strings.Join(req.Header[http.CanonicalHeaderKey(name)], ",")
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders = strings.Join(headerNames, ";")
+
+	canonical = strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	return canonical, signedHeaders
+}
+
+func (s *v4Signer) stringToSign(amzDate, canonicalRequest string) string {
+	return strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		s.credentialScope(),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+}
+
+// sign adds x-amz-date, x-amz-content-sha256, and Authorization headers
+// to req, and returns the "seed" signature — the first link in the chain
+// of signatures used to authenticate a streaming payload's chunks.
+func (s *v4Signer) sign(req *http.Request, payloadHash string) (seedSignature string) {
+	amzDate := s.t.Format(iso8601Basic)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	canonical, signedHeaders := s.canonicalRequest(req, payloadHash)
+	toSign := s.stringToSign(amzDate, canonical)
+	sig := hex.EncodeToString(hmacSHA256(s.key, []byte(toSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKey, s.credentialScope(), signedHeaders, sig))
+	return sig
+}
+
+// chunkSignature computes the signature of one streaming-payload chunk,
+// chained from the previous chunk's signature (or the seed signature,
+// for the first chunk).
+func (s *v4Signer) chunkSignature(amzDate, prevSignature string, chunk []byte) string {
+	toSign := strings.Join([]string{
+		sigV4ChunkPayload,
+		amzDate,
+		s.credentialScope(),
+		prevSignature,
+		emptySha256,
+		sha256Hex(chunk),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.key, []byte(toSign)))
+}
+
+// streamingBodyEncodedLength returns the Content-Length of a payload of
+// size n once framed as STREAMING-AWS4-HMAC-SHA256-PAYLOAD chunks.
+func streamingBodyEncodedLength(n int64) int64 {
+	var total int64
+	for n > 0 {
+		chunk := n
+		if chunk > sigV4ChunkSize {
+			chunk = sigV4ChunkSize
+		}
+		total += chunkFrameLen(chunk)
+		n -= chunk
+	}
+	total += chunkFrameLen(0) // terminating zero-length chunk
+	return total
+}
+
+// chunkFrameLen is the encoded length of one chunk of size n: its
+// hex-length-and-signature header, the data itself, and the trailing
+// CRLF after the data.
+func chunkFrameLen(n int64) int64 {
+	header := fmt.Sprintf("%x;chunk-signature=%064x\r\n", n, 0)
+	return int64(len(header)) + n + 2
+}
+
+// streamingBody wraps r, emitting it as a sequence of signed
+// STREAMING-AWS4-HMAC-SHA256-PAYLOAD chunks.
+type streamingBody struct {
+	r       io.Reader
+	signer  *v4Signer
+	amzDate string
+	prevSig string
+
+	frame   []byte // buffered, already-framed bytes not yet returned to the caller
+	readBuf []byte
+	done    bool
+}
+
+func newStreamingBody(r io.Reader, signer *v4Signer, amzDate, seedSignature string) *streamingBody {
+	return &streamingBody{
+		r:       r,
+		signer:  signer,
+		amzDate: amzDate,
+		prevSig: seedSignature,
+		readBuf: make([]byte, sigV4ChunkSize),
+	}
+}
+
+func (b *streamingBody) Read(p []byte) (int, error) {
+	for len(b.frame) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(b.r, b.readBuf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return 0, err
+		}
+		chunk := b.readBuf[:n]
+		sig := b.signer.chunkSignature(b.amzDate, b.prevSig, chunk)
+		b.prevSig = sig
+		b.frame = append(b.frame, fmt.Sprintf("%x;chunk-signature=%s\r\n", n, sig)...)
+		b.frame = append(b.frame, chunk...)
+		b.frame = append(b.frame, "\r\n"...)
+		if n == 0 || err == io.EOF {
+			b.done = true
+		}
+	}
+	n := copy(p, b.frame)
+	b.frame = b.frame[n:]
+	return n, nil
+}
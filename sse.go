@@ -0,0 +1,99 @@
+package s3gof3r
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+)
+
+// SSE algorithm identifiers for EncryptionConfig.Algorithm.
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+)
+
+// EncryptionConfig selects server-side encryption for a put. Set
+// Config.Encryption to encrypt every object uploaded with that Config.
+//
+// For SSE-S3, set Algorithm to SSEAlgorithmAES256 and leave the rest
+// zero. For SSE-KMS, set Algorithm to SSEAlgorithmKMS and, optionally,
+// KMSKeyID (S3 uses the account's default CMK when it's empty). For
+// SSE-C, leave Algorithm unset and set CustomerAlgorithm (normally
+// SSEAlgorithmAES256) and CustomerKey to the raw, unencoded key;
+// CustomerKeyMD5 is computed automatically if left empty.
+//
+// SSE-C additionally requires these same customer-key headers on every
+// GET of the object, not just the PUT that created it; Bucket.GetReader
+// adds them automatically via setGetHeaders.
+type EncryptionConfig struct {
+	Algorithm string
+	KMSKeyID  string
+
+	CustomerAlgorithm string
+	CustomerKey       string
+	CustomerKeyMD5    string
+}
+
+// sseC reports whether e configures SSE-C (customer-supplied keys).
+func (e *EncryptionConfig) sseC() bool {
+	return e != nil && e.CustomerKey != ""
+}
+
+// skipMd5OfParts reports whether e changes S3's ETag semantics enough
+// that the usual md5-of-part-md5s check against the completed object's
+// ETag would spuriously fail. S3 returns an opaque (non-MD5) ETag for
+// SSE-KMS and SSE-C objects, per
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/UsingKMSEncryption.html.
+func (e *EncryptionConfig) skipMd5OfParts() bool {
+	return e != nil && (e.Algorithm == SSEAlgorithmKMS || e.sseC())
+}
+
+// setHeaders adds the x-amz-server-side-encryption* headers e implies to
+// h. It is called on both the initiate-multipart POST and every
+// UploadPart PUT, since S3 requires the SSE-C headers on each part.
+func (e *EncryptionConfig) setHeaders(h http.Header) {
+	if e == nil {
+		return
+	}
+	if e.sseC() {
+		e.setCustomerKeyHeaders(h)
+		return
+	}
+	if e.Algorithm == "" {
+		return
+	}
+	h.Set("x-amz-server-side-encryption", e.Algorithm)
+	if e.Algorithm == SSEAlgorithmKMS && e.KMSKeyID != "" {
+		h.Set("x-amz-server-side-encryption-aws-kms-key-id", e.KMSKeyID)
+	}
+}
+
+// setGetHeaders adds the customer-key headers e requires on a GET, if e
+// configures SSE-C. SSE-S3 and SSE-KMS objects are decrypted by S3
+// transparently and take no headers on GET at all.
+func (e *EncryptionConfig) setGetHeaders(h http.Header) {
+	if !e.sseC() {
+		return
+	}
+	e.setCustomerKeyHeaders(h)
+}
+
+// setCustomerKeyHeaders adds the x-amz-server-side-encryption-customer-*
+// headers for e's SSE-C key to h. Callers must check e.sseC() first.
+func (e *EncryptionConfig) setCustomerKeyHeaders(h http.Header) {
+	alg := e.CustomerAlgorithm
+	if alg == "" {
+		alg = SSEAlgorithmAES256
+	}
+	h.Set("x-amz-server-side-encryption-customer-algorithm", alg)
+	h.Set("x-amz-server-side-encryption-customer-key", base64.StdEncoding.EncodeToString([]byte(e.CustomerKey)))
+	h.Set("x-amz-server-side-encryption-customer-key-MD5", e.customerKeyMD5())
+}
+
+func (e *EncryptionConfig) customerKeyMD5() string {
+	if e.CustomerKeyMD5 != "" {
+		return e.CustomerKeyMD5
+	}
+	sum := md5.Sum([]byte(e.CustomerKey))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,48 @@
+package s3gof3r
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestSetGetHeadersSSEC checks that GetReader's SSE-C support (setGetHeaders)
+// sends the same customer-key headers setHeaders sends on PUT, since S3
+// requires them on every GET of an SSE-C object too.
+func TestSetGetHeadersSSEC(t *testing.T) {
+	put := make(http.Header)
+	get := make(http.Header)
+	e := &EncryptionConfig{CustomerKey: "0123456789abcdef0123456789abcdef"}
+	e.setHeaders(put)
+	e.setGetHeaders(get)
+
+	for _, name := range []string{
+		"x-amz-server-side-encryption-customer-algorithm",
+		"x-amz-server-side-encryption-customer-key",
+		"x-amz-server-side-encryption-customer-key-MD5",
+	} {
+		if get.Get(name) == "" {
+			t.Fatalf("setGetHeaders: missing %s", name)
+		}
+		if get.Get(name) != put.Get(name) {
+			t.Fatalf("setGetHeaders %s = %q, want %q (same as setHeaders)", name, get.Get(name), put.Get(name))
+		}
+	}
+}
+
+// TestSetGetHeadersNonSSEC checks that SSE-S3 and SSE-KMS configs, and a nil
+// EncryptionConfig, add no headers to a GET: S3 decrypts those transparently
+// and rejects customer-key headers it doesn't expect.
+func TestSetGetHeadersNonSSEC(t *testing.T) {
+	configs := []*EncryptionConfig{
+		nil,
+		{Algorithm: SSEAlgorithmAES256},
+		{Algorithm: SSEAlgorithmKMS, KMSKeyID: "arn:aws:kms:us-east-1:1234:key/abc"},
+	}
+	for _, e := range configs {
+		h := make(http.Header)
+		e.setGetHeaders(h)
+		if len(h) != 0 {
+			t.Fatalf("setGetHeaders(%+v): expected no headers, got %v", e, h)
+		}
+	}
+}